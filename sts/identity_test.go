@@ -0,0 +1,78 @@
+package sts
+
+import (
+	"launchpad.net/goamz/aws"
+	"testing"
+	"time"
+)
+
+// TestPresignGetCallerIdentityGoldenVector pins PresignGetCallerIdentity's
+// output to a fixed time and a caller-supplied extraHeaders nonce header,
+// using the well-known AWS documentation test credentials. A mistake in
+// host selection or canonicalization here doesn't fail a Go test on its
+// own merits elsewhere: it surfaces as a remote verifier (Vault, IRSA)
+// silently rejecting, or misattributing, real presigned requests.
+func TestPresignGetCallerIdentityGoldenVector(t *testing.T) {
+	auth := aws.Auth{"AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", ""}
+	s := New(auth, aws.Region{STSEndpoint: "https://sts.amazonaws.com"})
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	const wantSignedHeaders = "content-type;host;x-amz-content-sha256;x-amz-date;x-vault-aws-iam-server-id"
+	const wantAuthorization = "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/sts/aws4_request, " +
+		"SignedHeaders=" + wantSignedHeaders + ", " +
+		"Signature=3b942d65fef4f7aa8d3a5b3a218b7fd89796f06cbba28baad201a236273069ab"
+	const wantPayloadHash = "ab821ae955788b0e33ebd34c208442ccfc2d406e2edc5e7a39bd6458fbb4f843"
+	const wantBody = "Action=GetCallerIdentity&Version=2011-06-15"
+	const wantURL = "https://sts.amazonaws.com/"
+
+	req, err := s.presignGetCallerIdentityAt(now, map[string]string{
+		"X-Vault-AWS-IAM-Server-ID": "vault.example.com",
+	})
+	if err != nil {
+		t.Fatalf("presignGetCallerIdentityAt() error = %v", err)
+	}
+
+	if req.Method != "POST" {
+		t.Errorf("Method = %q, want %q", req.Method, "POST")
+	}
+	if req.URL != wantURL {
+		t.Errorf("URL = %q, want %q", req.URL, wantURL)
+	}
+	if req.Body != wantBody {
+		t.Errorf("Body = %q, want %q", req.Body, wantBody)
+	}
+	if got := req.Headers["X-Vault-AWS-IAM-Server-ID"]; got != "vault.example.com" {
+		t.Errorf("extraHeaders did not survive into Headers: got %q", got)
+	}
+	if got := req.Headers["X-Amz-Content-Sha256"]; got != wantPayloadHash {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, wantPayloadHash)
+	}
+	if got := req.Headers["X-Amz-Date"]; got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q, want %q", got, "20150830T123600Z")
+	}
+	if got := req.Headers["Authorization"]; got != wantAuthorization {
+		t.Errorf("Authorization =\n%q\nwant\n%q", got, wantAuthorization)
+	}
+}
+
+// TestPresignGetCallerIdentityUsesRegionalEndpoint checks that
+// UseRegionalEndpoint changes both the request URL and the Host header used
+// in the signature, matching the host selection query() itself uses.
+func TestPresignGetCallerIdentityUsesRegionalEndpoint(t *testing.T) {
+	auth := aws.Auth{"AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", ""}
+	s := New(auth, aws.Region{Name: "eu-west-1", STSEndpoint: "https://sts.amazonaws.com"})
+	s.UseRegionalEndpoint = true
+
+	req, err := s.presignGetCallerIdentityAt(time.Now(), nil)
+	if err != nil {
+		t.Fatalf("presignGetCallerIdentityAt() error = %v", err)
+	}
+
+	const wantHost = "sts.eu-west-1.amazonaws.com"
+	if req.Headers["Host"] != wantHost {
+		t.Errorf("Host header = %q, want %q", req.Headers["Host"], wantHost)
+	}
+	if want := "https://" + wantHost + "/"; req.URL != want {
+		t.Errorf("URL = %q, want %q", req.URL, want)
+	}
+}
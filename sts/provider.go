@@ -0,0 +1,110 @@
+package sts
+
+import (
+	"launchpad.net/goamz/aws"
+	"sync"
+	"time"
+)
+
+// defaultExpiryWindow is how far ahead of the hard credential expiration a
+// STSCredentialsProvider forces a refresh, absent an explicit ExpiryWindow.
+const defaultExpiryWindow = 5 * time.Minute
+
+// CredentialsFunc retrieves a fresh set of temporary credentials, typically
+// by calling one of GetFederationToken, AssumeRole, or
+// AssumeRoleWithWebIdentity.
+type CredentialsFunc func() (Credentials, error)
+
+// STSCredentialsProvider wraps an STS call and transparently re-invokes it
+// before the previously issued Credentials.Expiration elapses. Retrieve
+// returns the current credentials as an aws.Auth value for any goamz service
+// (for example s3.New(auth, region)). The provider is not itself an
+// aws.Auth: a service built from one Retrieve result keeps those credentials
+// and does not see later refreshes.
+type STSCredentialsProvider struct {
+	// Retrieve calls to obtain new credentials.
+	Retriever CredentialsFunc
+
+	// ExpiryWindow forces a refresh this far ahead of the hard expiry.
+	// Defaults to 5 minutes if zero.
+	ExpiryWindow time.Duration
+
+	mu    sync.Mutex
+	creds Credentials
+}
+
+// NewCredentialsProvider returns a STSCredentialsProvider that refreshes via
+// retriever.
+func NewCredentialsProvider(retriever CredentialsFunc) *STSCredentialsProvider {
+	return &STSCredentialsProvider{Retriever: retriever}
+}
+
+// FederationTokenProvider returns a STSCredentialsProvider backed by
+// GetFederationToken.
+func (sts *STS) FederationTokenProvider(duration int, name, policy string) *STSCredentialsProvider {
+	return NewCredentialsProvider(func() (Credentials, error) {
+		resp, err := sts.GetFederationToken(duration, name, policy)
+		if err != nil {
+			return Credentials{}, err
+		}
+		return resp.Credentials, nil
+	})
+}
+
+// AssumeRoleProvider returns a STSCredentialsProvider backed by AssumeRole.
+func (sts *STS) AssumeRoleProvider(input *AssumeRoleInput) *STSCredentialsProvider {
+	return NewCredentialsProvider(func() (Credentials, error) {
+		resp, err := sts.AssumeRole(input)
+		if err != nil {
+			return Credentials{}, err
+		}
+		return resp.Credentials, nil
+	})
+}
+
+// AssumeRoleWithWebIdentityProvider returns a STSCredentialsProvider backed
+// by AssumeRoleWithWebIdentity.
+func (sts *STS) AssumeRoleWithWebIdentityProvider(input *AssumeRoleWithWebIdentityInput) *STSCredentialsProvider {
+	return NewCredentialsProvider(func() (Credentials, error) {
+		resp, err := sts.AssumeRoleWithWebIdentity(input)
+		if err != nil {
+			return Credentials{}, err
+		}
+		return resp.Credentials, nil
+	})
+}
+
+// IsExpired reports whether the cached credentials are missing or within
+// ExpiryWindow of their hard expiration.
+func (p *STSCredentialsProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isExpiredLocked()
+}
+
+func (p *STSCredentialsProvider) isExpiredLocked() bool {
+	if p.creds.Expiration.IsZero() {
+		return true
+	}
+	window := p.ExpiryWindow
+	if window == 0 {
+		window = defaultExpiryWindow
+	}
+	return time.Now().Add(window).After(p.creds.Expiration)
+}
+
+// Retrieve returns the current credentials as an aws.Auth, calling Retriever
+// to refresh them first if they are expired or within ExpiryWindow of
+// expiring.
+func (p *STSCredentialsProvider) Retrieve() (aws.Auth, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.isExpiredLocked() {
+		creds, err := p.Retriever()
+		if err != nil {
+			return aws.Auth{}, err
+		}
+		p.creds = creds
+	}
+	return p.creds.Auth(), nil
+}
@@ -0,0 +1,64 @@
+package sts
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// RetryConfig controls how STS.query retries throttled and transiently
+// failing requests. STS aggressively throttles AssumeRole and
+// GetFederationToken under load, so retrying with backoff is the default
+// rather than an opt-in.
+type RetryConfig struct {
+	// MaxRetries is the number of retries attempted after the initial
+	// request. 3 when STS.Retry is nil; 0 disables retries.
+	MaxRetries int
+
+	// BaseDelay is the backoff base. 30ms when STS.Retry is nil.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the computed backoff, before jitter. 20s when STS.Retry
+	// is nil; 0 leaves the backoff uncapped.
+	MaxDelay time.Duration
+}
+
+var defaultRetryConfig = RetryConfig{
+	MaxRetries: 3,
+	BaseDelay:  30 * time.Millisecond,
+	MaxDelay:   20 * time.Second,
+}
+
+func (sts *STS) retryConfig() RetryConfig {
+	if sts.Retry == nil {
+		return defaultRetryConfig
+	}
+	return *sts.Retry
+}
+
+// retryable reports whether e represents a throttling or transient-server
+// failure that is worth retrying.
+func retryable(e *Error) bool {
+	if e.StatusCode >= 500 {
+		return true
+	}
+	switch e.Code {
+	case "RequestLimitExceeded", "ServiceUnavailable":
+		return true
+	}
+	return strings.HasPrefix(e.Code, "Throttling")
+}
+
+// backoff returns how long to sleep before the retry numbered attempt
+// (0-based): min(cfg.MaxDelay, cfg.BaseDelay*2^attempt) plus uniform jitter
+// in [0, delay).
+func backoff(cfg RetryConfig, attempt int) time.Duration {
+	delay := cfg.BaseDelay * time.Duration(uint64(1)<<uint(attempt))
+	if cfg.MaxDelay > 0 && (delay > cfg.MaxDelay || delay <= 0) {
+		delay = cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)))
+}
@@ -0,0 +1,134 @@
+package sts
+
+import (
+	"fmt"
+	"launchpad.net/goamz/aws"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryableClassification(t *testing.T) {
+	cases := []struct {
+		code       string
+		statusCode int
+		want       bool
+	}{
+		{code: "Throttling", statusCode: 400, want: true},
+		{code: "ThrottlingException", statusCode: 400, want: true},
+		{code: "RequestLimitExceeded", statusCode: 400, want: true},
+		{code: "ServiceUnavailable", statusCode: 503, want: true},
+		{code: "", statusCode: 500, want: true},
+		{code: "", statusCode: 502, want: true},
+		{code: "AccessDenied", statusCode: 403, want: false},
+		{code: "ValidationError", statusCode: 400, want: false},
+		{code: "", statusCode: 429, want: false},
+	}
+	for _, c := range cases {
+		e := &Error{Code: c.code, StatusCode: c.statusCode}
+		if got := retryable(e); got != c.want {
+			t.Errorf("retryable(%+v) = %v, want %v", e, got, c.want)
+		}
+	}
+}
+
+func TestBackoffRespectsBaseAndMaxDelay(t *testing.T) {
+	cfg := RetryConfig{MaxRetries: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond}
+
+	if d := backoff(cfg, 0); d < cfg.BaseDelay || d >= 2*cfg.BaseDelay {
+		t.Errorf("backoff(attempt=0) = %v, want in [%v, %v)", d, cfg.BaseDelay, 2*cfg.BaseDelay)
+	}
+	if d := backoff(cfg, 10); d < cfg.MaxDelay || d >= 2*cfg.MaxDelay {
+		t.Errorf("backoff(attempt=10) = %v, want in [%v, %v) once capped at MaxDelay", d, cfg.MaxDelay, 2*cfg.MaxDelay)
+	}
+}
+
+// TestRawQueryRetriesThrottledCall checks that a 503 followed by a 200
+// succeeds without the caller ever seeing the transient failure.
+func TestRawQueryRetriesThrottledCall(t *testing.T) {
+	var calls int
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "<Response><ResponseMetadata><RequestId>abc-123</RequestId></ResponseMetadata></Response>")
+	}))
+	defer ts.Close()
+
+	prevClient := http.DefaultClient
+	http.DefaultClient = ts.Client()
+	defer func() { http.DefaultClient = prevClient }()
+
+	s := New(aws.Auth{"key", "secret", ""}, aws.Region{Name: "us-east-1", STSEndpoint: ts.URL})
+	s.Retry = &RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	resp := new(SimpleResp)
+	if err := s.query(map[string]string{"Action": "GetCallerIdentity"}, resp); err != nil {
+		t.Fatalf("query() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (one throttled, one success)", calls)
+	}
+	if resp.RequestId != "abc-123" {
+		t.Errorf("RequestId = %q, want %q", resp.RequestId, "abc-123")
+	}
+}
+
+// TestRawQueryHonorsExplicitMaxRetriesZero checks that an explicit
+// &RetryConfig{MaxRetries: 0} disables retries entirely, rather than being
+// mistaken for an unset Retry field and falling back to the default of 3.
+func TestRawQueryHonorsExplicitMaxRetriesZero(t *testing.T) {
+	var calls int
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	prevClient := http.DefaultClient
+	http.DefaultClient = ts.Client()
+	defer func() { http.DefaultClient = prevClient }()
+
+	s := New(aws.Auth{"key", "secret", ""}, aws.Region{Name: "us-east-1", STSEndpoint: ts.URL})
+	s.Retry = &RetryConfig{MaxRetries: 0, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	resp := new(SimpleResp)
+	err := s.query(map[string]string{"Action": "GetCallerIdentity"}, resp)
+	if err == nil {
+		t.Fatal("query() error = nil, want a throttling error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (MaxRetries: 0 must disable retries)", calls)
+	}
+}
+
+// TestRawQueryDoesNotRetryTerminalError checks that a non-retryable error
+// (403 AccessDenied) is returned immediately without consuming a retry.
+func TestRawQueryDoesNotRetryTerminalError(t *testing.T) {
+	var calls int
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<ErrorResponse><Error><Code>AccessDenied</Code><Message>nope</Message></Error></ErrorResponse>")
+	}))
+	defer ts.Close()
+
+	prevClient := http.DefaultClient
+	http.DefaultClient = ts.Client()
+	defer func() { http.DefaultClient = prevClient }()
+
+	s := New(aws.Auth{"key", "secret", ""}, aws.Region{Name: "us-east-1", STSEndpoint: ts.URL})
+	s.Retry = &RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	resp := new(SimpleResp)
+	err := s.query(map[string]string{"Action": "GetCallerIdentity"}, resp)
+	if err == nil {
+		t.Fatal("query() error = nil, want AccessDenied error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (no retries for a terminal error)", calls)
+	}
+}
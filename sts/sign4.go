@@ -0,0 +1,111 @@
+package sts
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"launchpad.net/goamz/aws"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sign4 signs an HTTP request with AWS Signature Version 4, setting
+// X-Amz-Date, X-Amz-Content-Sha256, X-Amz-Security-Token (if auth carries a
+// session token), and Authorization on headers in place. path is the
+// canonical request path (e.g. "/"). If unsignedPayload is true, the
+// payload hash is the literal "UNSIGNED-PAYLOAD" sentinel rather than a
+// hash of body. now is taken as a parameter, rather than read internally,
+// so callers (and tests) can produce a reproducible signature.
+func sign4(auth aws.Auth, method, path string, headers map[string]string, body []byte, region, service string, unsignedPayload bool, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	headers["X-Amz-Date"] = amzDate
+	if auth.Token != "" {
+		headers["X-Amz-Security-Token"] = auth.Token
+	}
+	payloadHash := "UNSIGNED-PAYLOAD"
+	if !unsignedPayload {
+		payloadHash = sha256Hex(body)
+	}
+	headers["X-Amz-Content-Sha256"] = payloadHash
+
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(headers)
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/%s/aws4_request", dateStamp, region, service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := signingKey(auth.SecretKey, dateStamp, region, service)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	headers["Authorization"] = fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		auth.AccessKey, credentialScope, signedHeaders, signature,
+	)
+}
+
+// canonicalizeHeaders returns the SigV4 SignedHeaders value and the
+// CanonicalHeaders block for headers.
+func canonicalizeHeaders(headers map[string]string) (signedHeaders, canonicalHeaders string) {
+	lower := make(map[string]string, len(headers))
+	names := make([]string, 0, len(headers))
+	for k, v := range headers {
+		lk := strings.ToLower(k)
+		lower[lk] = trimHeaderValue(v)
+		names = append(names, lk)
+	}
+	sort.Strings(names)
+	var buf strings.Builder
+	for _, n := range names {
+		buf.WriteString(n)
+		buf.WriteString(":")
+		buf.WriteString(lower[n])
+		buf.WriteString("\n")
+	}
+	return strings.Join(names, ";"), buf.String()
+}
+
+// trimHeaderValue trims leading/trailing whitespace and collapses internal
+// whitespace runs to a single space, per the SigV4 CanonicalHeaders spec.
+// This matters because PresignGetCallerIdentity lets callers add arbitrary
+// extraHeaders to the signed set; a caller-supplied value with repeated
+// spaces must canonicalize the same way AWS's own verifier canonicalizes it.
+func trimHeaderValue(v string) string {
+	return strings.Join(strings.Fields(v), " ")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signingKey derives the SigV4 signing key: kSecret -> kDate -> kRegion ->
+// kService -> kSigning.
+func signingKey(secretKey, dateStamp, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), dateStamp)
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, service)
+	return hmacSHA256(kService, "aws4_request")
+}
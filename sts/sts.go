@@ -3,11 +3,14 @@
 package sts
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"io/ioutil"
 	"launchpad.net/goamz/aws"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,31 +18,107 @@ import (
 type STS struct {
 	aws.Auth
 	aws.Region
+
+	// UseRegionalEndpoint routes requests to the regional STS endpoint for
+	// sts.Region (e.g. sts.eu-west-1.amazonaws.com) instead of the global
+	// sts.amazonaws.com endpoint. Regional endpoints are preferred for
+	// latency, availability, and VPC-endpoint routing, and are required by
+	// some regions that are not enabled in the global endpoint by default.
+	UseRegionalEndpoint bool
+
+	// UnsignedPayload sets x-amz-content-sha256 to the literal
+	// "UNSIGNED-PAYLOAD" sentinel instead of hashing the request body on
+	// signed requests. It has no effect on AssumeRoleWithSAML and
+	// AssumeRoleWithWebIdentity, which are sent without any signature.
+	UnsignedPayload bool
+
+	// Retry controls backoff for throttled or transiently failing calls. A
+	// nil Retry uses the package defaults (see RetryConfig); a non-nil
+	// Retry is used exactly as given, so callers can set MaxRetries: 0 to
+	// disable retries entirely.
+	Retry *RetryConfig
 }
 
 // New creates a new IAM instance.
 func New(auth aws.Auth, region aws.Region) *STS {
-	return &STS{auth, region}
+	return &STS{Auth: auth, Region: region}
 }
 
-func (sts *STS) query(params map[string]string, resp interface{}) error {
-	params["Version"] = "2011-06-15"
-	params["Timestamp"] = time.Now().In(time.UTC).Format(time.RFC3339)
-	endpoint, err := url.Parse(sts.STSEndpoint)
-	if err != nil {
-		return err
+// RegionalEndpoint returns the regional STS host for region, e.g.
+// "sts.eu-west-1.amazonaws.com".
+func RegionalEndpoint(region string) string {
+	return "sts." + region + ".amazonaws.com"
+}
+
+func (sts *STS) endpointHost() string {
+	if sts.UseRegionalEndpoint && sts.Region.Name != "" {
+		return RegionalEndpoint(sts.Region.Name)
 	}
-	sign(sts.Auth, "GET", "/", params, endpoint.Host)
-	endpoint.RawQuery = multimap(params).Encode()
-	r, err := http.Get(endpoint.String())
-	if err != nil {
-		return err
+	if endpoint, err := url.Parse(sts.STSEndpoint); err == nil && endpoint.Host != "" {
+		return endpoint.Host
 	}
-	defer r.Body.Close()
-	if r.StatusCode > 200 {
-		return buildError(r)
+	return sts.STSEndpoint
+}
+
+// signingRegion returns the SigV4 region to sign with, defaulting to
+// us-east-1 when sts.Region.Name is unset.
+func (sts *STS) signingRegion() string {
+	if sts.Region.Name != "" {
+		return sts.Region.Name
+	}
+	return "us-east-1"
+}
+
+func (sts *STS) query(params map[string]string, resp interface{}) error {
+	return sts.rawQuery(params, resp, false)
+}
+
+// unsignedQuery issues a request without a SigV4 signature. It is used by
+// the AssumeRoleWithSAML and AssumeRoleWithWebIdentity actions, which are
+// called before the caller holds any AWS credentials to sign with.
+func (sts *STS) unsignedQuery(params map[string]string, resp interface{}) error {
+	return sts.rawQuery(params, resp, true)
+}
+
+func (sts *STS) rawQuery(params map[string]string, resp interface{}, skipSigning bool) error {
+	params["Version"] = "2011-06-15"
+	host := sts.endpointHost()
+	region := sts.signingRegion()
+	cfg := sts.retryConfig()
+
+	for attempt := 0; ; attempt++ {
+		body := multimap(params).Encode()
+		headers := map[string]string{
+			"Host":         host,
+			"Content-Type": "application/x-www-form-urlencoded; charset=utf-8",
+		}
+		if !skipSigning {
+			sign4(sts.Auth, "POST", "/", headers, []byte(body), region, "sts", sts.UnsignedPayload, time.Now())
+		}
+
+		req, err := http.NewRequest("POST", "https://"+host+"/", strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if r.StatusCode >= 400 {
+			qerr := buildError(r)
+			r.Body.Close()
+			if ae, ok := qerr.(*Error); ok && attempt < cfg.MaxRetries && retryable(ae) {
+				time.Sleep(backoff(cfg, attempt))
+				continue
+			}
+			return qerr
+		}
+		defer r.Body.Close()
+		return xml.NewDecoder(r.Body).Decode(resp)
 	}
-	return xml.NewDecoder(r.Body).Decode(resp)
 }
 
 func buildError(r *http.Response) error {
@@ -47,7 +126,8 @@ func buildError(r *http.Response) error {
 		err    Error
 		errors xmlErrors
 	)
-	xml.NewDecoder(r.Body).Decode(&errors)
+	body, _ := ioutil.ReadAll(r.Body)
+	xml.Unmarshal(body, &errors)
 	if len(errors.Errors) > 0 {
 		err = errors.Errors[0]
 	}
@@ -55,6 +135,7 @@ func buildError(r *http.Response) error {
 	if err.Message == "" {
 		err.Message = r.Status
 	}
+	err.raw = body
 	return &err
 }
 
@@ -80,6 +161,187 @@ func (sts *STS) GetFederationToken(duration int, name, policy string) (*GetFeder
 	return resp, nil
 }
 
+// DecodeAuthorizationMessage decodes additional information about an
+// authorization status from an encoded message returned in the
+// EncodedAuthorizationMessage element of an AccessDenied error, typically
+// obtained via (*Error).EncodedAuthorizationMessage.
+func (sts *STS) DecodeAuthorizationMessage(encodedMessage string) (*DecodeAuthorizationMessageResp, error) {
+	params := map[string]string{
+		"Action":         "DecodeAuthorizationMessage",
+		"EncodedMessage": encodedMessage,
+	}
+	resp := new(DecodeAuthorizationMessageResp)
+	if err := sts.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type DecodeAuthorizationMessageResp struct {
+	RequestId      string `xml:"ResponseMetadata>RequestId"`
+	DecodedMessage string `xml:"DecodeAuthorizationMessageResult>DecodedMessage"`
+}
+
+// Decode parses the JSON policy-evaluation trace carried in
+// DecodedMessage.
+func (r *DecodeAuthorizationMessageResp) Decode() (*DecodedMessage, error) {
+	var dm DecodedMessage
+	if err := json.Unmarshal([]byte(r.DecodedMessage), &dm); err != nil {
+		return nil, err
+	}
+	return &dm, nil
+}
+
+// DecodedMessage is the parsed form of DecodeAuthorizationMessageResp's
+// DecodedMessage JSON policy-evaluation trace.
+type DecodedMessage struct {
+	Allowed           bool          `json:"allowed"`
+	ExplicitDeny      bool          `json:"explicitDeny"`
+	MatchedStatements []interface{} `json:"matchedStatements"`
+	Failures          []interface{} `json:"failures"`
+	Context           interface{}   `json:"context"`
+}
+
+// AssumeRoleInput holds the parameters for the AssumeRole action.
+type AssumeRoleInput struct {
+	RoleArn         string
+	RoleSessionName string
+	DurationSeconds int
+	Policy          string
+	ExternalId      string
+	SerialNumber    string
+	TokenCode       string
+}
+
+// AssumeRole returns a set of temporary credentials for an IAM role that the
+// caller is permitted to assume.
+func (sts *STS) AssumeRole(input *AssumeRoleInput) (*AssumeRoleResp, error) {
+	params := map[string]string{
+		"Action":          "AssumeRole",
+		"RoleArn":         input.RoleArn,
+		"RoleSessionName": input.RoleSessionName,
+	}
+	if input.DurationSeconds > 0 {
+		params["DurationSeconds"] = strconv.FormatInt(int64(input.DurationSeconds), 10)
+	}
+	if input.Policy != "" {
+		params["Policy"] = input.Policy
+	}
+	if input.ExternalId != "" {
+		params["ExternalId"] = input.ExternalId
+	}
+	if input.SerialNumber != "" {
+		params["SerialNumber"] = input.SerialNumber
+	}
+	if input.TokenCode != "" {
+		params["TokenCode"] = input.TokenCode
+	}
+	resp := new(AssumeRoleResp)
+	if err := sts.query(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type AssumeRoleResp struct {
+	RequestId        string          `xml:"ResponseMetadata>RequestId"`
+	Credentials      Credentials     `xml:"AssumeRoleResult>Credentials"`
+	AssumedRoleUser  AssumedRoleUser `xml:"AssumeRoleResult>AssumedRoleUser"`
+	PackedPolicySize int             `xml:"AssumeRoleResult>PackedPolicySize"`
+}
+
+// AssumeRoleWithSAMLInput holds the parameters for the AssumeRoleWithSAML
+// action.
+type AssumeRoleWithSAMLInput struct {
+	RoleArn         string
+	PrincipalArn    string
+	SAMLAssertion   string
+	DurationSeconds int
+	Policy          string
+}
+
+// AssumeRoleWithSAML returns a set of temporary credentials for a user
+// federated through a SAML identity provider. The request is not signed,
+// since a caller authenticating via SAML holds no AWS credentials yet.
+func (sts *STS) AssumeRoleWithSAML(input *AssumeRoleWithSAMLInput) (*AssumeRoleWithSAMLResp, error) {
+	params := map[string]string{
+		"Action":        "AssumeRoleWithSAML",
+		"RoleArn":       input.RoleArn,
+		"PrincipalArn":  input.PrincipalArn,
+		"SAMLAssertion": input.SAMLAssertion,
+	}
+	if input.DurationSeconds > 0 {
+		params["DurationSeconds"] = strconv.FormatInt(int64(input.DurationSeconds), 10)
+	}
+	if input.Policy != "" {
+		params["Policy"] = input.Policy
+	}
+	resp := new(AssumeRoleWithSAMLResp)
+	if err := sts.unsignedQuery(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type AssumeRoleWithSAMLResp struct {
+	RequestId        string          `xml:"ResponseMetadata>RequestId"`
+	Credentials      Credentials     `xml:"AssumeRoleWithSAMLResult>Credentials"`
+	AssumedRoleUser  AssumedRoleUser `xml:"AssumeRoleWithSAMLResult>AssumedRoleUser"`
+	PackedPolicySize int             `xml:"AssumeRoleWithSAMLResult>PackedPolicySize"`
+}
+
+// AssumeRoleWithWebIdentityInput holds the parameters for the
+// AssumeRoleWithWebIdentity action.
+type AssumeRoleWithWebIdentityInput struct {
+	RoleArn          string
+	RoleSessionName  string
+	WebIdentityToken string
+	ProviderId       string
+	DurationSeconds  int
+	Policy           string
+}
+
+// AssumeRoleWithWebIdentity returns a set of temporary credentials for a user
+// authenticated through an OpenID Connect or OAuth 2.0 web identity provider.
+// The request is not signed, since a caller authenticating via a web
+// identity token holds no AWS credentials yet.
+func (sts *STS) AssumeRoleWithWebIdentity(input *AssumeRoleWithWebIdentityInput) (*AssumeRoleWithWebIdentityResp, error) {
+	params := map[string]string{
+		"Action":           "AssumeRoleWithWebIdentity",
+		"RoleArn":          input.RoleArn,
+		"RoleSessionName":  input.RoleSessionName,
+		"WebIdentityToken": input.WebIdentityToken,
+	}
+	if input.ProviderId != "" {
+		params["ProviderId"] = input.ProviderId
+	}
+	if input.DurationSeconds > 0 {
+		params["DurationSeconds"] = strconv.FormatInt(int64(input.DurationSeconds), 10)
+	}
+	if input.Policy != "" {
+		params["Policy"] = input.Policy
+	}
+	resp := new(AssumeRoleWithWebIdentityResp)
+	if err := sts.unsignedQuery(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type AssumeRoleWithWebIdentityResp struct {
+	RequestId        string          `xml:"ResponseMetadata>RequestId"`
+	Credentials      Credentials     `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	AssumedRoleUser  AssumedRoleUser `xml:"AssumeRoleWithWebIdentityResult>AssumedRoleUser"`
+	PackedPolicySize int             `xml:"AssumeRoleWithWebIdentityResult>PackedPolicySize"`
+}
+
+// AssumedRoleUser identifies the role and session that a set of temporary
+// credentials were issued for.
+type AssumedRoleUser struct {
+	Arn           string `xml:"Arn"`
+	AssumedRoleId string `xml:"AssumedRoleId"`
+}
+
 type GetFederationTokenResp struct {
 	RequestId        string        `xml:"ResponseMetadata>RequestId"`
 	Credentials      Credentials   `xml:"GetFederationTokenResult>Credentials"`
@@ -121,6 +383,11 @@ type Error struct {
 
 	// Message explaining the error.
 	Message string
+
+	// raw is the undecoded error response body, kept around so
+	// EncodedAuthorizationMessage can pull elements out of it that
+	// xmlErrors doesn't map.
+	raw []byte
 }
 
 func (e *Error) Error() string {
@@ -133,3 +400,22 @@ func (e *Error) Error() string {
 	}
 	return prefix + e.Message
 }
+
+// EncodedAuthorizationMessage extracts the EncodedAuthorizationMessage
+// element that STS and other AWS services populate automatically on an
+// AccessDenied response when the request was explicitly or implicitly
+// denied. The result can be passed to STS.DecodeAuthorizationMessage to see
+// why the request was denied. It returns "" if the error response did not
+// carry one.
+func (e *Error) EncodedAuthorizationMessage() string {
+	if len(e.raw) == 0 {
+		return ""
+	}
+	var encoded struct {
+		Message string `xml:"Error>EncodedAuthorizationMessage"`
+	}
+	if err := xml.Unmarshal(e.raw, &encoded); err != nil {
+		return ""
+	}
+	return encoded.Message
+}
@@ -0,0 +1,129 @@
+package sts
+
+import (
+	"fmt"
+	"launchpad.net/goamz/aws"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const sampleAccessDeniedBody = `<ErrorResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <Error>
+    <Type>Sender</Type>
+    <Code>AccessDenied</Code>
+    <Message>User is not authorized to perform this action</Message>
+    <EncodedAuthorizationMessage>eyJhbGxvd2VkIjpmYWxzZX0</EncodedAuthorizationMessage>
+  </Error>
+  <RequestId>abc-123</RequestId>
+</ErrorResponse>`
+
+func TestErrorEncodedAuthorizationMessage(t *testing.T) {
+	e := &Error{raw: []byte(sampleAccessDeniedBody)}
+	if got, want := e.EncodedAuthorizationMessage(), "eyJhbGxvd2VkIjpmYWxzZX0"; got != want {
+		t.Errorf("EncodedAuthorizationMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestErrorEncodedAuthorizationMessageAbsent(t *testing.T) {
+	e := &Error{raw: []byte(`<ErrorResponse><Error><Code>ValidationError</Code></Error></ErrorResponse>`)}
+	if got := e.EncodedAuthorizationMessage(); got != "" {
+		t.Errorf("EncodedAuthorizationMessage() = %q, want \"\"", got)
+	}
+	if got := (&Error{}).EncodedAuthorizationMessage(); got != "" {
+		t.Errorf("EncodedAuthorizationMessage() on an Error with no raw body = %q, want \"\"", got)
+	}
+}
+
+const sampleDecodedMessageJSON = `{` +
+	`"allowed":false,` +
+	`"explicitDeny":true,` +
+	`"matchedStatements":[{"sid":"DenyAll"}],` +
+	`"failures":[],` +
+	`"context":{"principal":{"id":"AIDACKCEVSQ6C2EXAMPLE"}}` +
+	`}`
+
+func TestDecodeAuthorizationMessageRespDecode(t *testing.T) {
+	resp := &DecodeAuthorizationMessageResp{DecodedMessage: sampleDecodedMessageJSON}
+	dm, err := resp.Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if dm.Allowed {
+		t.Error("Allowed = true, want false")
+	}
+	if !dm.ExplicitDeny {
+		t.Error("ExplicitDeny = false, want true")
+	}
+	if len(dm.MatchedStatements) != 1 {
+		t.Errorf("len(MatchedStatements) = %d, want 1", len(dm.MatchedStatements))
+	}
+	if len(dm.Failures) != 0 {
+		t.Errorf("len(Failures) = %d, want 0", len(dm.Failures))
+	}
+}
+
+// TestDecodeAuthorizationMessagePipeline exercises the documented
+// sts.DecodeAuthorizationMessage(err.EncodedAuthorizationMessage()) pipeline
+// end to end: an AccessDenied response from one call yields an
+// EncodedAuthorizationMessage, which is then fed into a second call against
+// the DecodeAuthorizationMessage action.
+func TestDecodeAuthorizationMessagePipeline(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("ParseForm() error = %v", err)
+		}
+		switch r.Form.Get("Action") {
+		case "GetCallerIdentity":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, sampleAccessDeniedBody)
+		case "DecodeAuthorizationMessage":
+			if got, want := r.Form.Get("EncodedMessage"), "eyJhbGxvd2VkIjpmYWxzZX0"; got != want {
+				t.Errorf("EncodedMessage = %q, want %q", got, want)
+			}
+			fmt.Fprintf(w, "<DecodeAuthorizationMessageResponse>"+
+				"<DecodeAuthorizationMessageResult><DecodedMessage>%s</DecodedMessage></DecodeAuthorizationMessageResult>"+
+				"<ResponseMetadata><RequestId>req-1</RequestId></ResponseMetadata>"+
+				"</DecodeAuthorizationMessageResponse>", sampleDecodedMessageJSON)
+		default:
+			t.Fatalf("unexpected Action %q", r.Form.Get("Action"))
+		}
+	}))
+	defer ts.Close()
+
+	prevClient := http.DefaultClient
+	http.DefaultClient = ts.Client()
+	defer func() { http.DefaultClient = prevClient }()
+
+	s := New(aws.Auth{"key", "secret", ""}, aws.Region{Name: "us-east-1", STSEndpoint: ts.URL})
+	s.Retry = &RetryConfig{MaxRetries: 0}
+
+	_, err := s.GetCallerIdentity()
+	if err == nil {
+		t.Fatal("GetCallerIdentity() error = nil, want AccessDenied")
+	}
+	ae, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("GetCallerIdentity() error type = %T, want *Error", err)
+	}
+
+	encoded := ae.EncodedAuthorizationMessage()
+	if encoded == "" {
+		t.Fatal("EncodedAuthorizationMessage() = \"\", want the encoded message from the AccessDenied body")
+	}
+
+	decodeResp, err := s.DecodeAuthorizationMessage(encoded)
+	if err != nil {
+		t.Fatalf("DecodeAuthorizationMessage() error = %v", err)
+	}
+	dm, err := decodeResp.Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if dm.Allowed {
+		t.Error("Allowed = true, want false")
+	}
+	if !dm.ExplicitDeny {
+		t.Error("ExplicitDeny = false, want true")
+	}
+}